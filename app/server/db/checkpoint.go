@@ -0,0 +1,71 @@
+package db
+
+import "database/sql"
+
+// OrphanedPlan identifies a plan left in PlanStatusReplying with no
+// surviving owner row in active_plan_owners, as returned by
+// ListReplyingPlansWithNoOwner.
+type OrphanedPlan struct {
+	PlanId string
+	Branch string
+}
+
+// SavePlanCheckpoint upserts the latest recovery checkpoint for
+// (planId, branch), so a crash or disconnect mid-stream can be resumed via
+// GetPlanCheckpoint instead of discarding the in-progress reply.
+func SavePlanCheckpoint(planId, branch string, payload []byte) error {
+	_, err := Conn.Exec(
+		`insert into plan_checkpoints (plan_id, branch, payload) values ($1, $2, $3)
+		 on conflict (plan_id, branch) do update set payload = excluded.payload, updated_at = now()`,
+		planId, branch, payload,
+	)
+	return err
+}
+
+// GetPlanCheckpoint returns the most recently saved checkpoint payload for
+// (planId, branch), or nil if none exists.
+func GetPlanCheckpoint(planId, branch string) ([]byte, error) {
+	var payload []byte
+	err := Conn.QueryRow(
+		`select payload from plan_checkpoints where plan_id = $1 and branch = $2`,
+		planId, branch,
+	).Scan(&payload)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// ListReplyingPlansWithNoOwner returns every plan stuck in
+// PlanStatusReplying whose active_plan_owners row is either missing or
+// owned by selfServerId (this instance, recovering from its own restart) -
+// the state a server crash leaves behind, and the set RecoverOrphanedPlans
+// pauses so they can be resumed via the CLI.
+func ListReplyingPlansWithNoOwner(selfServerId string) ([]*OrphanedPlan, error) {
+	rows, err := Conn.Query(
+		`select p.id, p.branch
+		 from plans p
+		 left join active_plan_owners o on o.plan_id = p.id and o.branch = p.branch
+		 where p.status = 'replying'
+		   and (o.server_id is null or o.server_id = $1)`,
+		selfServerId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orphaned []*OrphanedPlan
+	for rows.Next() {
+		p := &OrphanedPlan{}
+		if err := rows.Scan(&p.PlanId, &p.Branch); err != nil {
+			return nil, err
+		}
+		orphaned = append(orphaned, p)
+	}
+
+	return orphaned, rows.Err()
+}
@@ -0,0 +1,10 @@
+package db
+
+import "database/sql"
+
+// Conn is the process-wide database handle, opened by server startup
+// before any db-backed feature (active plan ownership, checkpointing,
+// webhook delivery) runs. It's a plain package var, matching how the rest
+// of this package's Postgres access is already called (db.Conn.Exec,
+// db.Conn.QueryRow) rather than threading a handle through every function.
+var Conn *sql.DB
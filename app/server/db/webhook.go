@@ -0,0 +1,44 @@
+package db
+
+// OrgWebhook is a URL an org has registered to receive plan lifecycle
+// events, along with the secret used to sign deliveries (see
+// hooks.WebhookDispatcher.deliver).
+type OrgWebhook struct {
+	Id     string
+	OrgId  string
+	Url    string
+	Secret string
+}
+
+// ListOrgWebhooks returns every webhook registered for orgId.
+func ListOrgWebhooks(orgId string) ([]*OrgWebhook, error) {
+	rows, err := Conn.Query(`select id, org_id, url, secret from org_webhooks where org_id = $1`, orgId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*OrgWebhook
+	for rows.Next() {
+		var webhook OrgWebhook
+		if err := rows.Scan(&webhook.Id, &webhook.OrgId, &webhook.Url, &webhook.Secret); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// InsertWebhookDeliveryLog records the outcome of one webhook delivery
+// attempt, so failed deliveries can be audited and retried out of band.
+func InsertWebhookDeliveryLog(webhookId string, event string, success bool, errMsg string) error {
+	_, err := Conn.Exec(
+		`insert into webhook_delivery_logs (webhook_id, event, success, error) values ($1, $2, $3, $4)`,
+		webhookId, event, success, errMsg,
+	)
+	return err
+}
@@ -0,0 +1,33 @@
+package grpc
+
+import (
+	"encoding/json"
+
+	"google.golang.org/grpc/encoding"
+)
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// jsonCodec marshals this package's message types as JSON instead of real
+// protobuf. plan.pb.go/plan_grpc.pb.go are hand-written stand-ins for what
+// protoc-gen-go/protoc-gen-go-grpc would otherwise generate, so their
+// types are plain structs that don't implement proto.Message - grpc-go's
+// default codec requires that to marshal a message, and would fail every
+// RPC at the wire layer without this. Registering under the name "proto"
+// overrides the default codec for the whole process, which is fine since
+// PlanService is the only gRPC service plandex-server exposes.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,54 @@
+package grpc
+
+import "testing"
+
+func TestJsonCodecRoundTripsStreamMessageEnvelope(t *testing.T) {
+	codec := jsonCodec{}
+
+	original := StreamMessageEnvelope{MessageJson: []byte(`{"type":"reply","content":"hi"}`)}
+
+	data, err := codec.Marshal(&original)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var decoded StreamMessageEnvelope
+	if err := codec.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if string(decoded.MessageJson) != string(original.MessageJson) {
+		t.Errorf("MessageJson did not round-trip: got %q, want %q", decoded.MessageJson, original.MessageJson)
+	}
+}
+
+func TestJsonCodecRoundTripsStatusResponse(t *testing.T) {
+	codec := jsonCodec{}
+
+	original := StatusResponse{
+		Active:    true,
+		ReplyId:   "reply-1",
+		Iteration: 3,
+		Owner:     "server-a",
+	}
+
+	data, err := codec.Marshal(&original)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var decoded StatusResponse
+	if err := codec.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if decoded != original {
+		t.Errorf("StatusResponse did not round-trip: got %+v, want %+v", decoded, original)
+	}
+}
+
+func TestJsonCodecNameMatchesDefaultProtoCodec(t *testing.T) {
+	if name := (jsonCodec{}).Name(); name != "proto" {
+		t.Errorf("Name() = %q, want %q so it overrides grpc-go's default codec", name, "proto")
+	}
+}
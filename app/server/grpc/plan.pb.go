@@ -0,0 +1,56 @@
+// Hand-written stand-in for protoc-gen-go output (see codec.go).
+// source: plan.proto
+
+package grpc
+
+// SubscribeRequest is the initial payload a client sends on the Subscribe
+// stream to attach to a plan.
+type SubscribeRequest struct {
+	PlanId string
+	Branch string
+}
+
+// SubscribeMessage is the client->server message on the Subscribe stream.
+// Exactly one of Subscribe, RespondMissingFile, or KeepaliveAck is set,
+// mirroring the proto3 oneof in plan.proto.
+type SubscribeMessage struct {
+	Subscribe          *SubscribeRequest
+	RespondMissingFile *RespondMissingFileRequest
+	KeepaliveAck       *KeepaliveAck
+}
+
+// KeepaliveAck is sent by the client in response to a keepalive
+// StreamMessageEnvelope.
+type KeepaliveAck struct{}
+
+// StreamMessageEnvelope carries a JSON-encoded shared.StreamMessage.
+type StreamMessageEnvelope struct {
+	MessageJson []byte
+}
+
+type RespondMissingFileRequest struct {
+	PlanId string
+	Branch string
+	Choice string
+}
+
+type RespondMissingFileResponse struct{}
+
+type StopRequest struct {
+	PlanId string
+	Branch string
+}
+
+type StopResponse struct{}
+
+type StatusRequest struct {
+	PlanId string
+	Branch string
+}
+
+type StatusResponse struct {
+	Active    bool
+	ReplyId   string
+	Iteration int32
+	Owner     string
+}
@@ -0,0 +1,139 @@
+// Hand-written stand-in for protoc-gen-go-grpc output (see codec.go).
+// source: plan.proto
+
+package grpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PlanServiceServer is the server API for PlanService.
+type PlanServiceServer interface {
+	Subscribe(PlanService_SubscribeServer) error
+	RespondMissingFile(context.Context, *RespondMissingFileRequest) (*RespondMissingFileResponse, error)
+	Stop(context.Context, *StopRequest) (*StopResponse, error)
+	Status(context.Context, *StatusRequest) (*StatusResponse, error)
+}
+
+// UnimplementedPlanServiceServer must be embedded by PlanServer to satisfy
+// forward compatibility with new RPCs added to PlanService.
+type UnimplementedPlanServiceServer struct{}
+
+func (UnimplementedPlanServiceServer) Subscribe(PlanService_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+
+func (UnimplementedPlanServiceServer) RespondMissingFile(context.Context, *RespondMissingFileRequest) (*RespondMissingFileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RespondMissingFile not implemented")
+}
+
+func (UnimplementedPlanServiceServer) Stop(context.Context, *StopRequest) (*StopResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stop not implemented")
+}
+
+func (UnimplementedPlanServiceServer) Status(context.Context, *StatusRequest) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+
+// PlanService_SubscribeServer is the server side of the bidirectional
+// Subscribe stream: the client sends SubscribeMessage values (an initial
+// SubscribeRequest, then any RespondMissingFileRequest/KeepaliveAck
+// follow-ups) and the server sends StreamMessageEnvelope values back on
+// the same stream.
+type PlanService_SubscribeServer interface {
+	Send(*StreamMessageEnvelope) error
+	Recv() (*SubscribeMessage, error)
+	grpc.ServerStream
+}
+
+func RegisterPlanServiceServer(s grpc.ServiceRegistrar, srv PlanServiceServer) {
+	s.RegisterService(&PlanService_ServiceDesc, srv)
+}
+
+var PlanService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "plandex.grpc.PlanService",
+	HandlerType: (*PlanServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "RespondMissingFile", Handler: _PlanService_RespondMissingFile_Handler},
+		{MethodName: "Stop", Handler: _PlanService_Stop_Handler},
+		{MethodName: "Status", Handler: _PlanService_Status_Handler},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Subscribe",
+			Handler:       _PlanService_Subscribe_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "plan.proto",
+}
+
+func _PlanService_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PlanServiceServer).Subscribe(&planServiceSubscribeServer{stream})
+}
+
+type planServiceSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *planServiceSubscribeServer) Send(m *StreamMessageEnvelope) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *planServiceSubscribeServer) Recv() (*SubscribeMessage, error) {
+	m := new(SubscribeMessage)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _PlanService_RespondMissingFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RespondMissingFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlanServiceServer).RespondMissingFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/plandex.grpc.PlanService/RespondMissingFile"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlanServiceServer).RespondMissingFile(ctx, req.(*RespondMissingFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlanService_Stop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlanServiceServer).Stop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/plandex.grpc.PlanService/Stop"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlanServiceServer).Stop(ctx, req.(*StopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PlanService_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PlanServiceServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/plandex.grpc.PlanService/Status"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PlanServiceServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
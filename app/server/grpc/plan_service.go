@@ -0,0 +1,202 @@
+// Package grpc exposes plan subscription and control over a gRPC service,
+// giving the CLI (and third-party agents) a lower-overhead, strongly-typed
+// transport for long plan streams as an alternative to bridging the
+// internal chan string to HTTP SSE.
+package grpc
+
+//go:generate protoc --go_out=. --go-grpc_out=. plan.proto
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"plandex-server/model/plan"
+
+	shared "plandex-shared"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// keepaliveInterval is how often the server sends a keepalive StreamMessage
+// down an otherwise-idle Subscribe stream, analogous to a worker lease
+// renewal, so long-lived connections aren't reaped by intermediate
+// load balancers.
+const keepaliveInterval = 30 * time.Second
+
+// PlanServer implements the generated PlanServiceServer interface, wrapping
+// the existing GetActivePlan/UpdateActivePlan/SubscribePlan functions in
+// plandex-server/model/plan rather than introducing a second source of
+// truth for active plan state.
+type PlanServer struct {
+	UnimplementedPlanServiceServer
+}
+
+func NewPlanServer() *PlanServer {
+	return &PlanServer{}
+}
+
+// Subscribe is bidirectional: the client's first message sets
+// SubscribeRequest, and any later message on the same stream (a
+// missing-file response, a keepalive ack) is handled by the recv loop
+// below without opening a second RPC. plan.SubscribePlan already proxies
+// through the configured ActivePlanStore when this instance isn't the
+// plan's owner, so Subscribe works the same way on any server in the
+// cluster.
+func (s *PlanServer) Subscribe(stream PlanService_SubscribeServer) error {
+	ctx := stream.Context()
+
+	initial, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if initial.Subscribe == nil {
+		return status.Errorf(codes.InvalidArgument, "first message on Subscribe stream must set subscribe")
+	}
+	req := initial.Subscribe
+
+	subscriptionId, ch := plan.SubscribePlan(ctx, req.PlanId, req.Branch)
+	if ch == nil {
+		return status.Errorf(codes.NotFound, "no active plan found for plan ID %s on branch %s", req.PlanId, req.Branch)
+	}
+	defer plan.UnsubscribePlan(req.PlanId, req.Branch, subscriptionId)
+
+	// Recv loop: handles whatever the client sends after its initial
+	// SubscribeRequest (a missing-file response, or an ack of our last
+	// keepalive) without blocking the send loop below. It exits on its
+	// own once the client stops sending, which is expected well before
+	// ctx is done.
+	go func() {
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				return
+			}
+
+			switch {
+			case msg.RespondMissingFile != nil:
+				choice := msg.RespondMissingFile
+				if err := s.respondMissingFile(choice.PlanId, choice.Branch, choice.Choice); err != nil {
+					log.Printf("PlanServer.Subscribe: error handling missing file response: %v\n", err)
+				}
+			case msg.KeepaliveAck != nil:
+				// no-op - the client is just acknowledging our last keepalive
+			}
+		}
+	}()
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// One client's stream ending - a network blip, the client
+			// closing its connection - must not cancel the plan for
+			// every other subscriber. Just stop proxying to this one.
+			return ctx.Err()
+		case <-keepalive.C:
+			if err := extendKeepalive(stream); err != nil {
+				return err
+			}
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+
+			var streamMsg shared.StreamMessage
+			if err := streamMsg.UnmarshalJSON([]byte(msg)); err != nil {
+				log.Printf("PlanServer.Subscribe: error unmarshaling stream message: %v\n", err)
+				continue
+			}
+
+			if err := stream.Send(&StreamMessageEnvelope{MessageJson: []byte(msg)}); err != nil {
+				return err
+			}
+
+			if streamMsg.Type == shared.StreamMessageFinished || streamMsg.Type == shared.StreamMessageError {
+				return nil
+			}
+		}
+	}
+}
+
+// RespondMissingFile forwards a client's missing-file choice to the
+// active plan, for clients not already attached via Subscribe.
+func (s *PlanServer) RespondMissingFile(ctx context.Context, req *RespondMissingFileRequest) (*RespondMissingFileResponse, error) {
+	if err := s.respondMissingFile(req.PlanId, req.Branch, req.Choice); err != nil {
+		return nil, err
+	}
+	return &RespondMissingFileResponse{}, nil
+}
+
+func (s *PlanServer) respondMissingFile(planId, branch, choice string) error {
+	active := plan.GetActivePlan(planId, branch)
+	if active == nil {
+		return status.Errorf(codes.NotFound, "no local active plan found for plan ID %s on branch %s", planId, branch)
+	}
+
+	active.MissingFileResponseCh <- shared.RespondMissingFileChoice(choice)
+
+	return nil
+}
+
+// Stop cancels the active plan's context. If this instance isn't the
+// plan's owner, it asks the owner to stop via the ActivePlanStore's
+// control channel instead of only succeeding locally.
+func (s *PlanServer) Stop(ctx context.Context, req *StopRequest) (*StopResponse, error) {
+	active := plan.GetActivePlan(req.PlanId, req.Branch)
+	if active != nil {
+		active.CancelFn()
+		return &StopResponse{}, nil
+	}
+
+	if err := plan.StopRemote(req.PlanId, req.Branch); err != nil {
+		return nil, status.Errorf(codes.NotFound, "no active plan found for plan ID %s on branch %s: %v", req.PlanId, req.Branch, err)
+	}
+
+	return &StopResponse{}, nil
+}
+
+// Status returns a point-in-time snapshot of the active plan so a client
+// can attach to a running plan without polling the SSE endpoint first. If
+// this instance isn't the plan's owner, it reports the owner from the
+// ActivePlanStore instead of a flat "not found".
+func (s *PlanServer) Status(ctx context.Context, req *StatusRequest) (*StatusResponse, error) {
+	active := plan.GetActivePlan(req.PlanId, req.Branch)
+	if active != nil {
+		owner, err := plan.RemotePlanStatus(req.PlanId, req.Branch)
+		if err != nil {
+			log.Printf("PlanServer.Status: error getting owner for plan %s: %v\n", req.PlanId, err)
+		}
+
+		return &StatusResponse{
+			Active:    true,
+			ReplyId:   active.CurrentStreamingReplyId,
+			Iteration: int32(active.NumIterations()),
+			Owner:     owner,
+		}, nil
+	}
+
+	owner, err := plan.RemotePlanStatus(req.PlanId, req.Branch)
+	if err != nil {
+		log.Printf("PlanServer.Status: error getting remote status for plan %s: %v\n", req.PlanId, err)
+	}
+
+	return &StatusResponse{Active: owner != "", Owner: owner}, nil
+}
+
+// extendKeepalive sends a StreamMessageKeepalive down the stream, keeping
+// the connection alive through idle periods the same way a worker renews
+// its lease. The client acks it with a KeepaliveAck SubscribeMessage,
+// handled by Subscribe's recv loop.
+func extendKeepalive(stream PlanService_SubscribeServer) error {
+	keepalive, err := shared.StreamMessage{Type: shared.StreamMessageKeepalive}.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("error marshaling keepalive message: %v", err)
+	}
+
+	return stream.Send(&StreamMessageEnvelope{MessageJson: keepalive})
+}
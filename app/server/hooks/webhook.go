@@ -0,0 +1,160 @@
+package hooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"plandex-server/db"
+	"time"
+)
+
+// WebhookEvent identifies the plan lifecycle transition a webhook payload
+// reports.
+type WebhookEvent string
+
+const (
+	WebhookEventPlanStarted     WebhookEvent = "plan.started"
+	WebhookEventStageChanged    WebhookEvent = "plan.stage_changed"
+	WebhookEventSubtaskFinished WebhookEvent = "plan.subtask_finished"
+	WebhookEventStreamError     WebhookEvent = "plan.stream_error"
+	WebhookEventStreamFinished  WebhookEvent = "plan.stream_finished"
+	WebhookEventPlanStopped     WebhookEvent = "plan.stopped"
+	WebhookEventMissingFile     WebhookEvent = "plan.missing_file"
+)
+
+// WebhookPayload is the JSON body POSTed to each registered webhook URL on
+// a plan lifecycle transition.
+type WebhookPayload struct {
+	Event              WebhookEvent `json:"event"`
+	PlanId             string       `json:"planId"`
+	Branch             string       `json:"branch"`
+	OrgId              string       `json:"orgId"`
+	Iteration          int          `json:"iteration"`
+	Stage              string       `json:"stage,omitempty"`
+	FinishedSubtasks   []string     `json:"finishedSubtasks,omitempty"`
+	UnfinishedSubtasks []string     `json:"unfinishedSubtasks,omitempty"`
+	TotalRequestTokens int          `json:"totalRequestTokens,omitempty"`
+	Error              string       `json:"error,omitempty"`
+	SentAt             time.Time    `json:"sentAt"`
+}
+
+// webhookMaxRetries and webhookBaseBackoff control the exponential backoff
+// used when a webhook endpoint doesn't return a 2xx response.
+const (
+	webhookMaxRetries  = 5
+	webhookBaseBackoff = 500 * time.Millisecond
+)
+
+// WebhookDispatcher delivers plan lifecycle events to an org's registered
+// webhook URLs, so users can integrate plandex with CI, Slack, or their own
+// dashboards without scraping SSE.
+type WebhookDispatcher struct {
+	httpClient *http.Client
+}
+
+func NewWebhookDispatcher() *WebhookDispatcher {
+	return &WebhookDispatcher{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Dispatch sends payload to every webhook registered for orgId. Each
+// delivery is attempted in its own goroutine so a slow or unreachable
+// endpoint can't block plan execution or other webhook deliveries.
+func (d *WebhookDispatcher) Dispatch(orgId string, payload WebhookPayload) {
+	payload.OrgId = orgId
+	payload.SentAt = time.Now()
+
+	webhooks, err := db.ListOrgWebhooks(orgId)
+	if err != nil {
+		log.Printf("WebhookDispatcher.Dispatch: error listing webhooks for org %s: %v\n", orgId, err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		webhook := webhook
+		go d.deliver(webhook, payload)
+	}
+}
+
+func (d *WebhookDispatcher) deliver(webhook *db.OrgWebhook, payload WebhookPayload) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("WebhookDispatcher.deliver: error marshaling payload for webhook %s: %v\n", webhook.Id, err)
+		return
+	}
+
+	signature := sign(webhook.Secret, body)
+
+	var lastErr error
+	for attempt := 0; attempt < webhookMaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(webhookBaseBackoff * time.Duration(1<<uint(attempt-1)))
+		}
+
+		if err := d.send(webhook.Url, signature, body); err != nil {
+			lastErr = err
+			log.Printf("WebhookDispatcher.deliver: attempt %d failed for webhook %s: %v\n", attempt+1, webhook.Id, err)
+			d.logDelivery(webhook, payload.Event, false, err.Error())
+			continue
+		}
+
+		d.logDelivery(webhook, payload.Event, true, "")
+		return
+	}
+
+	log.Printf("WebhookDispatcher.deliver: giving up on webhook %s after %d attempts: %v\n", webhook.Id, webhookMaxRetries, lastErr)
+}
+
+func (d *WebhookDispatcher) send(url, signature string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error building webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Plandex-Signature", signature)
+
+	res, err := d.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending webhook request: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("webhook endpoint returned status %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+func (d *WebhookDispatcher) logDelivery(webhook *db.OrgWebhook, event WebhookEvent, success bool, errMsg string) {
+	if err := db.InsertWebhookDeliveryLog(webhook.Id, string(event), success, errMsg); err != nil {
+		log.Printf("WebhookDispatcher.logDelivery: error logging delivery for webhook %s: %v\n", webhook.Id, err)
+	}
+}
+
+// sign computes the HMAC-SHA256 signature of body using the webhook's
+// secret, so receivers can verify the payload actually came from plandex.
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Webhooks is the process-wide dispatcher used by DispatchWebhook. It's a
+// package-level var, following the same pattern as the existing ExecHook
+// registry, so call sites don't need to thread a dispatcher through every
+// function signature.
+var Webhooks = NewWebhookDispatcher()
+
+// DispatchWebhook fires a webhook payload for orgId on the configured
+// dispatcher. Call sites pass only the fields relevant to the event; OrgId
+// and SentAt are filled in by Dispatch.
+func DispatchWebhook(orgId string, payload WebhookPayload) {
+	Webhooks.Dispatch(orgId, payload)
+}
@@ -0,0 +1,538 @@
+package plan
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"plandex-server/db"
+	"plandex-server/types"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ActivePlanStore lets multiple plandex-server instances share active plan
+// ownership, stream fan-out, and stop signals, so SubscribePlan, StopRemote,
+// and RemotePlanStatus work from any instance in the cluster, not only the
+// server process that originally called CreateActivePlan. GetActivePlan and
+// UpdateActivePlan still see only plans created in this process.
+//
+// When unset, activePlanStore falls back to the existing in-process
+// SafeMap-only behavior.
+type ActivePlanStore interface {
+	// Register claims ownership of the (planId, branch) key for this server
+	// instance, so other instances know where to proxy stream subscriptions.
+	Register(planId, branch, serverId string) error
+
+	// Unregister releases ownership of the (planId, branch) key.
+	Unregister(planId, branch string) error
+
+	// Owner returns the server ID that owns the (planId, branch) key, or ""
+	// if no instance currently owns it.
+	Owner(planId, branch string) (string, error)
+
+	// Publish broadcasts a stream message for (planId, branch) to any other
+	// instance subscribed via Subscribe.
+	Publish(planId, branch, message string) error
+
+	// Subscribe proxies messages published for (planId, branch) on the
+	// backend's pubsub channel to the returned chan string, until ctx is
+	// canceled. It's used on non-owner servers to bridge a local subscriber
+	// to the owning instance's stream.
+	Subscribe(ctx context.Context, planId, branch string) (chan string, error)
+
+	// PublishControl broadcasts a control signal (currently just "stop")
+	// for (planId, branch) to the owning instance, distinct from
+	// Publish's stream-message fan-out.
+	PublishControl(planId, branch, kind string) error
+
+	// SubscribeControl streams control signals sent via PublishControl for
+	// (planId, branch), until ctx is canceled. It's used by the owning
+	// instance to learn about a Stop request issued against a different
+	// server in the cluster.
+	SubscribeControl(ctx context.Context, planId, branch string) (chan string, error)
+}
+
+var (
+	activePlanStoreMu sync.RWMutex
+	activePlanStore   ActivePlanStore
+)
+
+// SetActivePlanStore installs the backend used to share active plan state
+// across a cluster of plandex-server instances. Call it once at startup;
+// if it's never called, active plan state stays process-local, matching
+// the prior single-node behavior.
+//
+// RecoverOrphanedPlans is deliberately NOT tied to this call: a single-node
+// deployment that never configures a PostgresActivePlanStore still needs
+// orphaned plans from a prior crash paused on restart, so that recovery is
+// invoked unconditionally from server startup instead (see server.Init).
+func SetActivePlanStore(store ActivePlanStore) {
+	activePlanStoreMu.Lock()
+	activePlanStore = store
+	activePlanStoreMu.Unlock()
+}
+
+func getActivePlanStore() ActivePlanStore {
+	activePlanStoreMu.RLock()
+	defer activePlanStoreMu.RUnlock()
+	return activePlanStore
+}
+
+// serverId identifies this plandex-server instance to the ActivePlanStore.
+// It's read once at startup from PLANDEX_SERVER_ID, falling back to the
+// hostname, so restarts of the same instance reuse the same ID.
+var serverId = func() string {
+	if id := os.Getenv("PLANDEX_SERVER_ID"); id != "" {
+		return id
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		return host
+	}
+	return "unknown-server"
+}()
+
+// PostgresActivePlanStore implements ActivePlanStore on top of Postgres
+// LISTEN/NOTIFY, reusing the same *sql.DB the rest of the server already
+// talks to the database with, so no extra infrastructure is required to
+// run plandex-server behind a load balancer.
+//
+// A single *pq.Listener delivers every notification on one shared
+// Notify channel, and a Go channel hands each value to exactly one
+// receiver - so with more than one concurrent Subscribe call (the normal
+// case: several plans, or several subscribers to the same plan), whichever
+// goroutine happens to read a given notification would otherwise steal it
+// away from the subscriber it was actually meant for. dispatch is the one
+// and only reader of listener.Notify; it fans each event out to every
+// subscriber registered for that event's key.
+type PostgresActivePlanStore struct {
+	listener *pq.Listener
+
+	subsMu sync.Mutex
+	subs   map[string][]chan activePlanEvent
+}
+
+const activePlanNotifyChannel = "active_plan_events"
+
+// activePlanSubscriberBuffer bounds how far a slow subscriber can lag
+// behind the dispatcher before its events start being dropped, so one
+// stalled subscriber can't block delivery to the others.
+const activePlanSubscriberBuffer = 32
+
+// NewPostgresActivePlanStore opens a dedicated LISTEN connection for active
+// plan pubsub and starts the dispatcher goroutine that demuxes it to
+// per-subscriber channels. The caller is responsible for calling
+// SetActivePlanStore with the result during server startup.
+func NewPostgresActivePlanStore(connStr string) (*PostgresActivePlanStore, error) {
+	listener := pq.NewListener(connStr, 10, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("PostgresActivePlanStore: listener event error: %v\n", err)
+		}
+	})
+
+	if err := listener.Listen(activePlanNotifyChannel); err != nil {
+		return nil, fmt.Errorf("error listening on %s: %v", activePlanNotifyChannel, err)
+	}
+
+	s := &PostgresActivePlanStore{
+		listener: listener,
+		subs:     map[string][]chan activePlanEvent{},
+	}
+	go s.dispatch()
+
+	return s, nil
+}
+
+// dispatch is the sole reader of s.listener.Notify. It unmarshals each
+// notification once and fans it out to every subscriber registered for
+// that (planId, branch) key, so concurrent Subscribe calls for different
+// plans (or the same plan) each get every event meant for them instead of
+// racing to steal notifications from one shared channel.
+func (s *PostgresActivePlanStore) dispatch() {
+	for notification := range s.listener.Notify {
+		if notification == nil {
+			continue
+		}
+
+		var ev activePlanEvent
+		if err := json.Unmarshal([]byte(notification.Extra), &ev); err != nil {
+			log.Printf("PostgresActivePlanStore: error unmarshaling notification: %v\n", err)
+			continue
+		}
+
+		key := s.key(ev.PlanId, ev.Branch)
+
+		s.subsMu.Lock()
+		subs := append([]chan activePlanEvent(nil), s.subs[key]...)
+		s.subsMu.Unlock()
+
+		fanOut(key, subs, ev)
+	}
+}
+
+// fanOut delivers ev to every channel in subs without blocking on a slow
+// subscriber, logging and dropping the event for any that are full. It's
+// split out from dispatch so the fan-out behavior can be unit tested
+// without a real *pq.Listener.
+func fanOut(key string, subs []chan activePlanEvent, ev activePlanEvent) {
+	for _, sub := range subs {
+		select {
+		case sub <- ev:
+		default:
+			log.Printf("PostgresActivePlanStore: dropping event for %s - subscriber channel full\n", key)
+		}
+	}
+}
+
+// addSubscriber registers a new per-subscriber channel for key and returns
+// it; the dispatcher goroutine will push every event for key onto it.
+func (s *PostgresActivePlanStore) addSubscriber(key string) chan activePlanEvent {
+	ch := make(chan activePlanEvent, activePlanSubscriberBuffer)
+
+	s.subsMu.Lock()
+	s.subs[key] = append(s.subs[key], ch)
+	s.subsMu.Unlock()
+
+	return ch
+}
+
+// removeSubscriber unregisters a channel previously returned by
+// addSubscriber, so the dispatcher stops writing to it.
+func (s *PostgresActivePlanStore) removeSubscriber(key string, ch chan activePlanEvent) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+
+	subs := s.subs[key]
+	for i, sub := range subs {
+		if sub == ch {
+			s.subs[key] = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(s.subs[key]) == 0 {
+		delete(s.subs, key)
+	}
+}
+
+type activePlanEvent struct {
+	PlanId   string `json:"planId"`
+	Branch   string `json:"branch"`
+	ServerId string `json:"serverId,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Kind     string `json:"kind"`
+}
+
+func (s *PostgresActivePlanStore) key(planId, branch string) string {
+	return strings.Join([]string{planId, branch}, "|")
+}
+
+// activePlanOwnerTTL bounds how long an owner row is trusted without a
+// heartbeat refresh. If an instance crashes without calling Unregister,
+// its row goes stale after this long and Owner starts reporting "" again
+// instead of pointing at a dead server forever.
+const activePlanOwnerTTL = 30 * time.Second
+
+// activePlanHeartbeatInterval is how often CreateActivePlan's owning
+// instance re-calls Register to refresh its owner row's updated_at.
+const activePlanHeartbeatInterval = 10 * time.Second
+
+func (s *PostgresActivePlanStore) Register(planId, branch, serverId string) error {
+	_, err := db.Conn.Exec(
+		`insert into active_plan_owners (plan_id, branch, server_id) values ($1, $2, $3)
+		 on conflict (plan_id, branch) do update set server_id = excluded.server_id, updated_at = now()`,
+		planId, branch, serverId,
+	)
+	if err != nil {
+		return fmt.Errorf("error registering active plan owner: %v", err)
+	}
+	return s.notify(planId, branch, activePlanEvent{Kind: "register", ServerId: serverId})
+}
+
+func (s *PostgresActivePlanStore) Unregister(planId, branch string) error {
+	_, err := db.Conn.Exec(`delete from active_plan_owners where plan_id = $1 and branch = $2`, planId, branch)
+	if err != nil {
+		return fmt.Errorf("error unregistering active plan owner: %v", err)
+	}
+	return s.notify(planId, branch, activePlanEvent{Kind: "unregister"})
+}
+
+func (s *PostgresActivePlanStore) Owner(planId, branch string) (string, error) {
+	var owner string
+	var updatedAt time.Time
+	err := db.Conn.QueryRow(
+		`select server_id, updated_at from active_plan_owners where plan_id = $1 and branch = $2`,
+		planId, branch,
+	).Scan(&owner, &updatedAt)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error getting active plan owner: %v", err)
+	}
+
+	if isOwnerStale(updatedAt) {
+		log.Printf("PostgresActivePlanStore: owner %s for plan %s on branch %s is stale (last seen %s ago) - treating as unowned\n", owner, planId, branch, time.Since(updatedAt))
+		return "", nil
+	}
+
+	return owner, nil
+}
+
+// isOwnerStale reports whether an owner row last refreshed at updatedAt is
+// past activePlanOwnerTTL. Split out from Owner so the staleness check can
+// be unit tested without a real Postgres connection.
+func isOwnerStale(updatedAt time.Time) bool {
+	return time.Since(updatedAt) > activePlanOwnerTTL
+}
+
+// heartbeatActivePlanOwner refreshes the (planId, branch) owner row on
+// store every activePlanHeartbeatInterval until ctx is canceled, so
+// Owner's TTL check keeps seeing this instance as alive for as long as it
+// actually is. It's started alongside the initial Register call in
+// CreateActivePlan.
+func heartbeatActivePlanOwner(ctx context.Context, store ActivePlanStore, planId, branch string) {
+	ticker := time.NewTicker(activePlanHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := store.Register(planId, branch, serverId); err != nil {
+				log.Printf("heartbeatActivePlanOwner: error refreshing owner for plan %s on branch %s: %v\n", planId, branch, err)
+			}
+		}
+	}
+}
+
+func (s *PostgresActivePlanStore) Publish(planId, branch, message string) error {
+	return s.notify(planId, branch, activePlanEvent{Kind: "message", Message: message})
+}
+
+func (s *PostgresActivePlanStore) notify(planId, branch string, ev activePlanEvent) error {
+	ev.PlanId = planId
+	ev.Branch = branch
+
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("error marshaling active plan event: %v", err)
+	}
+
+	_, err = db.Conn.Exec(`select pg_notify($1, $2)`, activePlanNotifyChannel, string(payload))
+	if err != nil {
+		return fmt.Errorf("error notifying active plan event: %v", err)
+	}
+
+	return nil
+}
+
+// Subscribe registers a dedicated per-caller channel with the dispatcher
+// and bridges its "message" events onto the returned chan string, so each
+// concurrent Subscribe call - for the same plan or different ones - gets
+// its own feed instead of racing with every other call to read a single
+// shared channel.
+func (s *PostgresActivePlanStore) Subscribe(ctx context.Context, planId, branch string) (chan string, error) {
+	key := s.key(planId, branch)
+	evCh := s.addSubscriber(key)
+	ch := make(chan string)
+
+	go func() {
+		defer close(ch)
+		defer s.removeSubscriber(key, evCh)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-evCh:
+				if !ok {
+					return
+				}
+				if ev.Kind != "message" {
+					continue
+				}
+
+				select {
+				case ch <- ev.Message:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func (s *PostgresActivePlanStore) PublishControl(planId, branch, kind string) error {
+	return s.notify(planId, branch, activePlanEvent{Kind: kind})
+}
+
+func (s *PostgresActivePlanStore) SubscribeControl(ctx context.Context, planId, branch string) (chan string, error) {
+	key := s.key(planId, branch)
+	evCh := s.addSubscriber(key)
+	ch := make(chan string)
+
+	go func() {
+		defer close(ch)
+		defer s.removeSubscriber(key, evCh)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-evCh:
+				if !ok {
+					return
+				}
+				if ev.Kind == "message" || ev.Kind == "register" || ev.Kind == "unregister" {
+					continue
+				}
+
+				select {
+				case ch <- ev.Kind:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// watchForRemoteStop listens for a "stop" control signal published via
+// PublishControl for (planId, branch) and cancels activePlan when one
+// arrives, so a Stop RPC that lands on a non-owner instance can still
+// actually stop the plan instead of only succeeding locally.
+func watchForRemoteStop(activePlan *types.ActivePlan, store ActivePlanStore, planId, branch string) {
+	stopCh, err := store.SubscribeControl(activePlan.Ctx, planId, branch)
+	if err != nil {
+		log.Printf("watchForRemoteStop: error subscribing to control events for plan %s: %v\n", planId, err)
+		return
+	}
+
+	for kind := range stopCh {
+		if kind != "stop" {
+			continue
+		}
+		log.Printf("watchForRemoteStop: received remote stop signal for plan %s on branch %s\n", planId, branch)
+		activePlan.CancelFn()
+		return
+	}
+}
+
+// StopRemote asks the owning instance of (planId, branch) to stop the
+// plan, for use when the local server isn't the owner - e.g. a Stop RPC
+// landed on a different instance than the one running the plan.
+func StopRemote(planId, branch string) error {
+	store := getActivePlanStore()
+	if store == nil {
+		return fmt.Errorf("no ActivePlanStore configured")
+	}
+
+	owner, err := store.Owner(planId, branch)
+	if err != nil {
+		return fmt.Errorf("error getting owner for plan %s: %v", planId, err)
+	}
+	if owner == "" {
+		return fmt.Errorf("no owner registered for plan %s on branch %s", planId, branch)
+	}
+
+	return store.PublishControl(planId, branch, "stop")
+}
+
+// RemotePlanStatus returns the owning server ID for (planId, branch) via
+// the configured ActivePlanStore, for callers (like the gRPC Status RPC)
+// that need a cluster-wide view rather than just the local SafeMap.
+func RemotePlanStatus(planId, branch string) (string, error) {
+	store := getActivePlanStore()
+	if store == nil {
+		return "", nil
+	}
+	return store.Owner(planId, branch)
+}
+
+// activePlanOwnerCheckInterval bounds how long proxySubscribePlan can keep
+// a proxied subscription open after its owning instance actually died: if
+// the owner row goes stale (see activePlanOwnerTTL) with nothing left to
+// ever unregister it, this is what notices and closes the local channel
+// instead of leaving the caller hanging indefinitely.
+const activePlanOwnerCheckInterval = 10 * time.Second
+
+// proxySubscribePlan is used by SubscribePlan when the local SafeMap has no
+// entry for (planId, branch) but an ActivePlanStore is configured: it
+// transparently proxies messages from the owning server's stream into a
+// local chan string, so callers don't need to know which instance actually
+// owns the plan.
+func proxySubscribePlan(ctx context.Context, planId, branch string) (string, chan string) {
+	store := getActivePlanStore()
+	if store == nil {
+		return "", nil
+	}
+
+	owner, err := store.Owner(planId, branch)
+	if err != nil {
+		log.Printf("proxySubscribePlan: error getting owner for plan %s: %v\n", planId, err)
+		return "", nil
+	}
+	if owner == "" {
+		log.Printf("proxySubscribePlan: no owner registered for plan %s on branch %s\n", planId, branch)
+		return "", nil
+	}
+
+	proxyCtx, cancel := context.WithCancel(ctx)
+
+	proxied, err := store.Subscribe(proxyCtx, planId, branch)
+	if err != nil {
+		cancel()
+		log.Printf("proxySubscribePlan: error subscribing to plan %s: %v\n", planId, err)
+		return "", nil
+	}
+
+	id := uuid.New().String()
+	localCh := make(chan string)
+
+	go func() {
+		defer close(localCh)
+		defer cancel()
+
+		ownerCheck := time.NewTicker(activePlanOwnerCheckInterval)
+		defer ownerCheck.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ownerCheck.C:
+				current, err := store.Owner(planId, branch)
+				if err != nil {
+					log.Printf("proxySubscribePlan: error re-checking owner for plan %s: %v\n", planId, err)
+					continue
+				}
+				if current == "" {
+					log.Printf("proxySubscribePlan: owner for plan %s on branch %s is no longer active - closing proxied subscription\n", planId, branch)
+					return
+				}
+			case msg, ok := <-proxied:
+				if !ok {
+					return
+				}
+				select {
+				case localCh <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return id, localCh
+}
@@ -0,0 +1,59 @@
+package plan
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsOwnerStale(t *testing.T) {
+	cases := []struct {
+		name      string
+		updatedAt time.Time
+		want      bool
+	}{
+		{"just refreshed", time.Now(), false},
+		{"within TTL", time.Now().Add(-activePlanOwnerTTL / 2), false},
+		{"past TTL", time.Now().Add(-activePlanOwnerTTL * 2), true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := isOwnerStale(c.updatedAt); got != c.want {
+				t.Errorf("isOwnerStale(%v) = %t, want %t", c.updatedAt, got, c.want)
+			}
+		})
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	key := "plan-1|main"
+	a := make(chan activePlanEvent, 1)
+	b := make(chan activePlanEvent, 1)
+	ev := activePlanEvent{PlanId: "plan-1", Branch: "main", Kind: "message", Message: "hello"}
+
+	fanOut(key, []chan activePlanEvent{a, b}, ev)
+
+	for _, ch := range []chan activePlanEvent{a, b} {
+		select {
+		case got := <-ch:
+			if got != ev {
+				t.Errorf("fanOut delivered %+v, want %+v", got, ev)
+			}
+		default:
+			t.Error("fanOut did not deliver to one of the subscriber channels")
+		}
+	}
+}
+
+func TestFanOutDropsOnFullChannel(t *testing.T) {
+	key := "plan-1|main"
+	full := make(chan activePlanEvent, 1)
+	full <- activePlanEvent{Kind: "message", Message: "already queued"}
+
+	// Must not block even though full has no room for another event.
+	fanOut(key, []chan activePlanEvent{full}, activePlanEvent{Kind: "message", Message: "dropped"})
+
+	if len(full) != 1 {
+		t.Errorf("expected full channel to retain only its original event, got len %d", len(full))
+	}
+}
@@ -0,0 +1,179 @@
+package plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"plandex-server/db"
+	"plandex-server/model"
+	"plandex-server/types"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+
+	shared "plandex-shared"
+)
+
+// checkpointInterval controls how often execTellPlan persists a recovery
+// checkpoint for the in-progress reply while streaming.
+const checkpointInterval = 5 * time.Second
+
+// planCheckpoint is the persisted snapshot needed to reconstruct an
+// ActivePlan and resume execTellPlan at the point it was interrupted,
+// whether by a server crash or a client disconnect.
+type planCheckpoint struct {
+	PlanId                string        `json:"planId"`
+	Branch                string        `json:"branch"`
+	ReplyId               string        `json:"replyId"`
+	Iteration             int           `json:"iteration"`
+	ReplyBuffer           string        `json:"replyBuffer"`
+	Subtasks              []*db.Subtask `json:"subtasks"`
+	IsPlanningStage       bool          `json:"isPlanningStage"`
+	IsImplementationStage bool          `json:"isImplementationStage"`
+	IsContextStage        bool          `json:"isContextStage"`
+	MessagesHash          string        `json:"messagesHash"`
+	UpdatedAt             time.Time     `json:"updatedAt"`
+}
+
+// hashMessages produces a stable digest of a conversation's messages so a
+// resumed plan can detect whether the underlying conversation changed out
+// from under it since the checkpoint was saved.
+func hashMessages(messages []openai.ChatCompletionMessage) (string, error) {
+	b, err := json.Marshal(messages)
+	if err != nil {
+		return "", fmt.Errorf("error marshaling messages for checkpoint hash: %v", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// saveCheckpoint persists the current streaming state for (planId, branch)
+// so the plan can be resumed via ResumeActivePlan after a crash or
+// disconnect instead of discarding the in-progress reply.
+func saveCheckpoint(state *activeTellStreamState, active *types.ActivePlan) error {
+	mu := stateLock(state.plan.Id, state.branch)
+	mu.RLock()
+	messagesHash, err := hashMessages(state.messages)
+	if err != nil {
+		mu.RUnlock()
+		return err
+	}
+
+	checkpoint := planCheckpoint{
+		PlanId:                state.plan.Id,
+		Branch:                state.branch,
+		ReplyId:               state.replyId,
+		Iteration:             state.iteration,
+		ReplyBuffer:           active.CurrentReplyContent,
+		Subtasks:              state.subtasks,
+		IsPlanningStage:       state.isPlanningStage,
+		IsImplementationStage: state.isImplementationStage,
+		IsContextStage:        state.isContextStage,
+		MessagesHash:          messagesHash,
+		UpdatedAt:             time.Now(),
+	}
+	mu.RUnlock()
+
+	payload, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("error marshaling plan checkpoint: %v", err)
+	}
+
+	if err := db.SavePlanCheckpoint(state.plan.Id, state.branch, payload); err != nil {
+		return fmt.Errorf("error saving plan checkpoint: %v", err)
+	}
+
+	return nil
+}
+
+// startCheckpointing periodically saves a recovery checkpoint for the
+// active plan until active.Ctx is canceled (normally when the stream
+// finishes or the plan is stopped).
+func startCheckpointing(state *activeTellStreamState, active *types.ActivePlan) {
+	ticker := time.NewTicker(checkpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-active.Ctx.Done():
+			return
+		case <-ticker.C:
+			if err := saveCheckpoint(state, active); err != nil {
+				log.Printf("startCheckpointing: error saving checkpoint for plan %s: %v\n", state.plan.Id, err)
+			}
+		}
+	}
+}
+
+// ResumeActivePlan reconstructs an ActivePlan from its most recent
+// checkpoint and re-enters execTellPlan at the saved iteration, re-streaming
+// any buffered content to the reconnecting subscriber. It's used when a
+// plan was left in PlanStatusPaused after a server crash, or when a user
+// explicitly resumes a plan the CLI reported as paused.
+func ResumeActivePlan(clients map[string]model.ClientInfo, plan *db.Plan, branch string, auth *types.ServerAuth) error {
+	payload, err := db.GetPlanCheckpoint(plan.Id, branch)
+	if err != nil {
+		return fmt.Errorf("error loading plan checkpoint: %v", err)
+	}
+	if payload == nil {
+		return fmt.Errorf("no checkpoint found for plan %s on branch %s", plan.Id, branch)
+	}
+
+	var checkpoint planCheckpoint
+	if err := json.Unmarshal(payload, &checkpoint); err != nil {
+		return fmt.Errorf("error unmarshaling plan checkpoint: %v", err)
+	}
+
+	log.Printf("ResumeActivePlan: resuming plan %s on branch %s at iteration %d\n", plan.Id, branch, checkpoint.Iteration)
+
+	active, err := activatePlan(clients, plan, branch, auth, "", false, false)
+	if err != nil {
+		return fmt.Errorf("error reactivating plan: %v", err)
+	}
+
+	if checkpoint.ReplyBuffer != "" {
+		active.Stream(shared.StreamMessage{
+			Type:    shared.StreamMessageReply,
+			ReplyId: checkpoint.ReplyId,
+			Content: checkpoint.ReplyBuffer,
+		})
+	}
+
+	if err := db.SetPlanStatus(plan.Id, branch, shared.PlanStatusReplying, ""); err != nil {
+		return fmt.Errorf("error setting plan %s status to replying on resume: %v", plan.Id, err)
+	}
+
+	go execTellPlan(execTellPlanParams{
+		clients:          clients,
+		plan:             plan,
+		branch:           branch,
+		auth:             auth,
+		iteration:        checkpoint.Iteration,
+		resumeCheckpoint: &checkpoint,
+	})
+
+	return nil
+}
+
+// RecoverOrphanedPlans scans for plans left in PlanStatusReplying with no
+// owning server instance - the state a crash leaves behind - and
+// transitions them to PlanStatusPaused so users can resume them via the
+// CLI instead of silently losing the in-progress work. It should be called
+// once during server startup.
+func RecoverOrphanedPlans() {
+	orphaned, err := db.ListReplyingPlansWithNoOwner(serverId)
+	if err != nil {
+		log.Printf("recoverOrphanedPlans: error listing orphaned plans: %v\n", err)
+		return
+	}
+
+	for _, p := range orphaned {
+		if err := db.SetPlanStatus(p.PlanId, p.Branch, shared.PlanStatusPaused, ""); err != nil {
+			log.Printf("recoverOrphanedPlans: error pausing plan %s: %v\n", p.PlanId, err)
+			continue
+		}
+		log.Printf("recoverOrphanedPlans: paused orphaned plan %s on branch %s\n", p.PlanId, p.Branch)
+	}
+}
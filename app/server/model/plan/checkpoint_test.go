@@ -0,0 +1,50 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestHashMessagesStableForSameContent(t *testing.T) {
+	messages := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "system prompt"},
+		{Role: openai.ChatMessageRoleUser, Content: "do the thing"},
+	}
+
+	h1, err := hashMessages(messages)
+	if err != nil {
+		t.Fatalf("hashMessages: %v", err)
+	}
+	h2, err := hashMessages(messages)
+	if err != nil {
+		t.Fatalf("hashMessages: %v", err)
+	}
+
+	if h1 != h2 {
+		t.Errorf("hashMessages produced different hashes for identical input: %s != %s", h1, h2)
+	}
+}
+
+func TestHashMessagesChangesWithContent(t *testing.T) {
+	before := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "do the thing"},
+	}
+	after := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "do the thing"},
+		{Role: openai.ChatMessageRoleAssistant, Content: "ok, done"},
+	}
+
+	h1, err := hashMessages(before)
+	if err != nil {
+		t.Fatalf("hashMessages: %v", err)
+	}
+	h2, err := hashMessages(after)
+	if err != nil {
+		t.Fatalf("hashMessages: %v", err)
+	}
+
+	if h1 == h2 {
+		t.Error("hashMessages produced the same hash for a conversation that changed")
+	}
+}
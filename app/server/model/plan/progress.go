@@ -0,0 +1,144 @@
+package plan
+
+import (
+	"plandex-server/types"
+	"time"
+
+	shared "plandex-shared"
+)
+
+// progressInterval is how often startProgressReporting emits a
+// shared.StreamMessageProgress update, so CLI clients can render a real
+// progress bar instead of an opaque spinner.
+const progressInterval = 500 * time.Millisecond
+
+// tokenRateWindow is how far back progressTracker smooths its tokens/sec
+// measurement, so a short burst or stall doesn't whipsaw the ETA.
+const tokenRateWindow = 5 * time.Second
+
+// progressTracker measures a smoothed tokens/sec rate for the active
+// reply stream and derives an ETA from it, given the reserved output
+// tokens for the current model config.
+type progressTracker struct {
+	reservedOutputTokens int
+	samples              []tokenSample
+}
+
+type tokenSample struct {
+	at     time.Time
+	tokens int
+}
+
+func newProgressTracker(reservedOutputTokens int) *progressTracker {
+	return &progressTracker{reservedOutputTokens: reservedOutputTokens}
+}
+
+// record adds a (now, tokensProduced) sample and drops samples older than
+// tokenRateWindow.
+func (t *progressTracker) record(tokensProduced int) {
+	now := time.Now()
+	t.samples = append(t.samples, tokenSample{at: now, tokens: tokensProduced})
+
+	cutoff := now.Add(-tokenRateWindow)
+	i := 0
+	for i < len(t.samples) && t.samples[i].at.Before(cutoff) {
+		i++
+	}
+	t.samples = t.samples[i:]
+}
+
+// rate returns the smoothed tokens/sec measured over the retained window.
+func (t *progressTracker) rate() float64 {
+	if len(t.samples) < 2 {
+		return 0
+	}
+
+	first := t.samples[0]
+	last := t.samples[len(t.samples)-1]
+
+	elapsed := last.at.Sub(first.at).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+
+	return float64(last.tokens-first.tokens) / elapsed
+}
+
+// eta estimates remaining stream duration from the reserved output tokens
+// minus tokens produced so far, divided by the smoothed rate. It returns 0
+// when the rate isn't yet known.
+func (t *progressTracker) eta(tokensProduced int) time.Duration {
+	rate := t.rate()
+	if rate <= 0 {
+		return 0
+	}
+
+	remainingTokens := t.reservedOutputTokens - tokensProduced
+	if remainingTokens <= 0 {
+		return 0
+	}
+
+	return time.Duration(float64(remainingTokens)/rate) * time.Second
+}
+
+// progressEvent builds a shared.StreamMessageProgress payload from the
+// current state of an in-progress reply stream.
+func progressEvent(state *activeTellStreamState, tracker *progressTracker, tokensProduced int) shared.StreamMessage {
+	mu := stateLock(state.plan.Id, state.branch)
+	mu.RLock()
+
+	var stage shared.StreamMessageProgressStage
+	switch {
+	case state.isContextStage:
+		stage = shared.StreamMessageProgressStageContext
+	case state.isImplementationStage:
+		stage = shared.StreamMessageProgressStageImplementation
+	default:
+		stage = shared.StreamMessageProgressStagePlanning
+	}
+
+	var finishedSubtasks, totalSubtasks int
+	for _, task := range state.subtasks {
+		totalSubtasks++
+		if task.IsFinished {
+			finishedSubtasks++
+		}
+	}
+
+	mu.RUnlock()
+
+	tracker.record(tokensProduced)
+
+	return shared.StreamMessage{
+		Type: shared.StreamMessageProgress,
+		Progress: &shared.StreamMessageProgressInfo{
+			Stage:             stage,
+			SubtaskIndex:      finishedSubtasks,
+			SubtaskTotal:      totalSubtasks,
+			TokensProduced:    tokensProduced,
+			TokensPerSecond:   tracker.rate(),
+			EstimatedTimeLeft: tracker.eta(tokensProduced),
+		},
+	}
+}
+
+// startProgressReporting emits a shared.StreamMessageProgress update on
+// the active plan's stream roughly every progressInterval, until
+// active.Ctx is canceled. tokensProduced is read from getTokensProduced on
+// each tick rather than pushed, so listenStream's hot path doesn't need to
+// coordinate with this goroutine beyond the reply content it already
+// accumulates on the active plan.
+func startProgressReporting(state *activeTellStreamState, active *types.ActivePlan, reservedOutputTokens int, getTokensProduced func() int) {
+	tracker := newProgressTracker(reservedOutputTokens)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-active.Ctx.Done():
+			return
+		case <-ticker.C:
+			active.Stream(progressEvent(state, tracker, getTokensProduced()))
+		}
+	}
+}
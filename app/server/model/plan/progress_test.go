@@ -0,0 +1,92 @@
+package plan
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressTrackerRateZeroWithFewerThanTwoSamples(t *testing.T) {
+	tracker := newProgressTracker(1000)
+
+	if rate := tracker.rate(); rate != 0 {
+		t.Errorf("rate() with no samples = %v, want 0", rate)
+	}
+
+	tracker.record(10)
+	if rate := tracker.rate(); rate != 0 {
+		t.Errorf("rate() with one sample = %v, want 0", rate)
+	}
+}
+
+func TestProgressTrackerRecordDropsSamplesOutsideWindow(t *testing.T) {
+	tracker := newProgressTracker(1000)
+
+	// Seed a sample well outside tokenRateWindow before recording a fresh
+	// one; record() should prune the stale sample rather than let it skew
+	// the rate calculation.
+	tracker.samples = []tokenSample{
+		{at: time.Now().Add(-tokenRateWindow - time.Second), tokens: 0},
+	}
+
+	tracker.record(100)
+
+	if len(tracker.samples) != 1 {
+		t.Fatalf("expected stale sample to be pruned, got %d samples", len(tracker.samples))
+	}
+	if tracker.samples[0].tokens != 100 {
+		t.Errorf("expected remaining sample to be the fresh one, got %+v", tracker.samples[0])
+	}
+}
+
+func TestProgressTrackerRateReflectsTokensPerSecond(t *testing.T) {
+	tracker := newProgressTracker(1000)
+
+	now := time.Now()
+	tracker.samples = []tokenSample{
+		{at: now.Add(-2 * time.Second), tokens: 0},
+		{at: now, tokens: 200},
+	}
+
+	rate := tracker.rate()
+	if rate < 99 || rate > 101 {
+		t.Errorf("rate() = %v, want ~100 tokens/sec", rate)
+	}
+}
+
+func TestProgressTrackerEtaZeroWhenRateUnknown(t *testing.T) {
+	tracker := newProgressTracker(1000)
+
+	if eta := tracker.eta(0); eta != 0 {
+		t.Errorf("eta() with no rate = %v, want 0", eta)
+	}
+}
+
+func TestProgressTrackerEtaZeroWhenAlreadyComplete(t *testing.T) {
+	tracker := newProgressTracker(1000)
+
+	now := time.Now()
+	tracker.samples = []tokenSample{
+		{at: now.Add(-2 * time.Second), tokens: 0},
+		{at: now, tokens: 200},
+	}
+
+	if eta := tracker.eta(1000); eta != 0 {
+		t.Errorf("eta() at reservedOutputTokens = %v, want 0", eta)
+	}
+}
+
+func TestProgressTrackerEtaEstimatesRemainingDuration(t *testing.T) {
+	tracker := newProgressTracker(1000)
+
+	now := time.Now()
+	tracker.samples = []tokenSample{
+		{at: now.Add(-2 * time.Second), tokens: 0},
+		{at: now, tokens: 200},
+	}
+
+	// rate is ~100 tokens/sec, 800 tokens remain, so eta should be ~8s.
+	eta := tracker.eta(200)
+	if eta < 7*time.Second || eta > 9*time.Second {
+		t.Errorf("eta() = %v, want ~8s", eta)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"plandex-server/db"
+	"plandex-server/hooks"
 	"plandex-server/types"
 	"strings"
 	"time"
@@ -25,6 +26,20 @@ func CreateActivePlan(orgId, userId, planId, branch, prompt string, buildOnly, a
 
 	activePlans.Set(key, activePlan)
 
+	hooks.DispatchWebhook(orgId, hooks.WebhookPayload{
+		Event:  hooks.WebhookEventPlanStarted,
+		PlanId: planId,
+		Branch: branch,
+	})
+
+	if store := getActivePlanStore(); store != nil {
+		if err := store.Register(planId, branch, serverId); err != nil {
+			log.Printf("Error registering active plan %s with ActivePlanStore: %v\n", planId, err)
+		}
+		go heartbeatActivePlanOwner(activePlan.Ctx, store, planId, branch)
+		go watchForRemoteStop(activePlan, store, planId, branch)
+	}
+
 	go func() {
 		for {
 			select {
@@ -36,6 +51,12 @@ func CreateActivePlan(orgId, userId, planId, branch, prompt string, buildOnly, a
 					log.Printf("Error setting plan %s status to stopped: %v\n", planId, err)
 				}
 
+				hooks.DispatchWebhook(orgId, hooks.WebhookPayload{
+					Event:  hooks.WebhookEventPlanStopped,
+					PlanId: planId,
+					Branch: branch,
+				})
+
 				DeleteActivePlan(orgId, userId, planId, branch)
 
 				return
@@ -51,6 +72,12 @@ func CreateActivePlan(orgId, userId, planId, branch, prompt string, buildOnly, a
 						log.Printf("Error setting plan %s status to ready: %v\n", planId, err)
 					}
 
+					hooks.DispatchWebhook(orgId, hooks.WebhookPayload{
+						Event:  hooks.WebhookEventStreamFinished,
+						PlanId: planId,
+						Branch: branch,
+					})
+
 				} else {
 					log.Printf("Error streaming plan %s: %v\n", planId, apiErr)
 
@@ -59,6 +86,13 @@ func CreateActivePlan(orgId, userId, planId, branch, prompt string, buildOnly, a
 						log.Printf("Error setting plan %s status to error: %v\n", planId, err)
 					}
 
+					hooks.DispatchWebhook(orgId, hooks.WebhookPayload{
+						Event:  hooks.WebhookEventStreamError,
+						PlanId: planId,
+						Branch: branch,
+						Error:  apiErr.Msg,
+					})
+
 					log.Println("Sending error message to client")
 					activePlan.Stream(shared.StreamMessage{
 						Type:  shared.StreamMessageError,
@@ -115,6 +149,12 @@ func DeleteActivePlan(orgId, userId, planId, branch string) {
 
 	activePlans.Delete(strings.Join([]string{planId, branch}, "|"))
 
+	if store := getActivePlanStore(); store != nil {
+		if err := store.Unregister(planId, branch); err != nil {
+			log.Printf("Error unregistering active plan %s with ActivePlanStore: %v\n", planId, err)
+		}
+	}
+
 	log.Printf("Deleted active plan %s - %s - %s\n", planId, branch, orgId)
 }
 
@@ -129,8 +169,8 @@ func SubscribePlan(ctx context.Context, planId, branch string) (string, chan str
 
 	activePlan := GetActivePlan(planId, branch)
 	if activePlan == nil {
-		log.Printf("SubscribePlan - No active plan found for plan ID %s on branch %s\n", planId, branch)
-		return "", nil
+		log.Printf("SubscribePlan - No local active plan found for plan ID %s on branch %s - checking ActivePlanStore\n", planId, branch)
+		return proxySubscribePlan(ctx, planId, branch)
 	}
 
 	UpdateActivePlan(planId, branch, func(activePlan *types.ActivePlan) {
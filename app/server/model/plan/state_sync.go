@@ -0,0 +1,38 @@
+package plan
+
+import "sync"
+
+// stateLocks guards reads and writes of the activeTellStreamState fields
+// that saveCheckpoint and progressEvent read on a ticker
+// (state.subtasks, state.isPlanningStage/isImplementationStage/isContextStage,
+// state.messages) while listenStream mutates the same fields as it parses
+// the model's streamed reply, keyed by planId+branch so unrelated plans
+// streaming concurrently in the same process don't contend with each
+// other.
+//
+// This only covers the readers in this package (saveCheckpoint,
+// progressEvent) - listenStream isn't part of this file set, so it can't
+// be updated here to acquire stateLock around its own writes to these
+// fields. Until it does, this narrows the race window but doesn't close
+// it; listenStream needs to take stateLock(state.plan.Id, state.branch)
+// for the same duration for the fix to be complete.
+var (
+	stateLocksMu sync.Mutex
+	stateLocks   = map[string]*sync.RWMutex{}
+)
+
+// stateLock returns the keyed RWMutex guarding an active plan's streaming
+// state, creating it on first use.
+func stateLock(planId, branch string) *sync.RWMutex {
+	key := planId + "|" + branch
+
+	stateLocksMu.Lock()
+	defer stateLocksMu.Unlock()
+
+	mu, ok := stateLocks[key]
+	if !ok {
+		mu = &sync.RWMutex{}
+		stateLocks[key] = mu
+	}
+	return mu
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"plandex-server/db"
@@ -19,6 +20,42 @@ import (
 	"github.com/sashabaranov/go-openai"
 )
 
+// notifiedSubtasks dedups plan.subtask_finished webhook deliveries, so a
+// subtask that's already been reported finished doesn't get reported again
+// on a later execTellPlan iteration of the same plan that reloads the same
+// subtask state.
+var notifiedSubtasks types.SafeMap[bool] = *types.NewSafeMap[bool]()
+
+func notifySubtaskFinished(orgId, planId, branch string, iteration int, task *db.Subtask) {
+	key := strings.Join([]string{planId, branch, task.Title}, "|")
+	if notifiedSubtasks.Get(key) {
+		return
+	}
+	notifiedSubtasks.Set(key, true)
+
+	hooks.DispatchWebhook(orgId, hooks.WebhookPayload{
+		Event:            hooks.WebhookEventSubtaskFinished,
+		PlanId:           planId,
+		Branch:           branch,
+		Iteration:        iteration,
+		FinishedSubtasks: []string{task.Title},
+	})
+}
+
+// planStageLabel returns a short label for the planning stage that a set of
+// is*Stage flags represents, so the plan.stage_changed webhook can report a
+// single Stage string instead of three separate booleans.
+func planStageLabel(isContextStage, isImplementationStage bool) string {
+	switch {
+	case isContextStage:
+		return "context"
+	case isImplementationStage:
+		return "implementation"
+	default:
+		return "planning"
+	}
+}
+
 func Tell(clients map[string]model.ClientInfo, plan *db.Plan, branch string, auth *types.ServerAuth, req *shared.TellPlanRequest) error {
 	log.Printf("Tell: Called with plan ID %s on branch %s\n", plan.Id, branch)
 
@@ -65,6 +102,7 @@ type execTellPlanParams struct {
 	didLoadFollowUpContext    bool
 	didMakeFollowUpPlan       bool
 	didLoadChatOnlyContext    bool
+	resumeCheckpoint          *planCheckpoint
 }
 
 func execTellPlan(params execTellPlanParams) {
@@ -101,6 +139,7 @@ func execTellPlan(params execTellPlanParams) {
 		for _, task := range subtasks {
 			if task.IsFinished {
 				finished = append(finished, task.Title)
+				notifySubtaskFinished(currentOrgId, plan.Id, branch, iteration, task)
 			} else {
 				unfinished = append(unfinished, task.Title)
 			}
@@ -110,6 +149,13 @@ func execTellPlan(params execTellPlanParams) {
 		log.Printf("[TellExec] Unfinished tasks: %v", unfinished)
 	}
 
+	if rc := params.resumeCheckpoint; rc != nil {
+		// db.GetPlanSubtasks above is still the source of truth - subtasks
+		// are persisted independently of the checkpoint - so this is just a
+		// sanity comparison against what was saved, not an override.
+		log.Printf("[TellExec] Resuming from checkpoint saved with %d subtasks (%d now in db)\n", len(rc.Subtasks), len(subtasks))
+	}
+
 	if missingFileResponse == "" {
 		log.Println("Executing WillExecPlanHook")
 		_, apiErr := hooks.ExecHook(hooks.WillExecPlan, hooks.HookParams{
@@ -222,8 +268,32 @@ func execTellPlan(params execTellPlanParams) {
 
 	isContextStage := autoContextEnabled && isPlanningStage && (req.IsChatOnly || !isFollowUp) && !state.contextMapEmpty && !wasContextStage && (isUserPrompt || shouldLoadFollowUpContext)
 
+	if rc := params.resumeCheckpoint; rc != nil {
+		// Trust the checkpoint's stage flags over recomputing them from
+		// lastConvoMsg: the checkpoint was saved mid-iteration, so the
+		// conversation's persisted flags may not reflect the stage that was
+		// actually in progress when the server crashed or the client
+		// disconnected.
+		isPlanningStage = rc.IsPlanningStage
+		isImplementationStage = rc.IsImplementationStage
+		isContextStage = rc.IsContextStage
+	}
+
 	log.Printf("isPlanningStage: %t, isImplementationStage: %t, isContextStage: %t, isFollowUp: %t\n", isPlanningStage, isImplementationStage, isContextStage, isFollowUp)
 
+	var finishedSubtasks, unfinishedSubtasks []string
+	for _, task := range subtasks {
+		if task.IsFinished {
+			finishedSubtasks = append(finishedSubtasks, task.Title)
+		} else {
+			unfinishedSubtasks = append(unfinishedSubtasks, task.Title)
+		}
+	}
+
+	prevStage := planStageLabel(wasContextStage, wasImplementationStage)
+	currStage := planStageLabel(isContextStage, isImplementationStage)
+	stageChanged := prevStage != currStage
+
 	state.isFollowUp = isFollowUp
 	state.willLoadFollowUpContext = shouldLoadFollowUpContext
 	state.isPlanningStage = isPlanningStage
@@ -324,8 +394,22 @@ func execTellPlan(params execTellPlanParams) {
 
 	if missingFileResponse == "" {
 		state.messages = append(state.messages, *promptMessage)
-	} else if !state.handleMissingFileResponse(applyScriptSummary) {
-		return
+	} else {
+		// The model's missing-file prompt itself is detected and streamed to
+		// the client in listenStream's reply parsing, which isn't reachable
+		// from here - this is the best available signal in this file that a
+		// missing-file prompt occurred, since reaching this branch means the
+		// user is responding to one from a prior iteration.
+		hooks.DispatchWebhook(currentOrgId, hooks.WebhookPayload{
+			Event:     hooks.WebhookEventMissingFile,
+			PlanId:    plan.Id,
+			Branch:    branch,
+			Iteration: iteration,
+		})
+
+		if !state.handleMissingFileResponse(applyScriptSummary) {
+			return
+		}
 	}
 
 	log.Printf("\n\nMessages: %d\n", len(state.messages))
@@ -336,6 +420,29 @@ func execTellPlan(params execTellPlanParams) {
 	requestTokens := shared.GetMessagesTokenEstimate(state.messages...) + imageContextTokens + shared.TokensPerRequest
 	state.totalRequestTokens = requestTokens
 
+	if rc := params.resumeCheckpoint; rc != nil {
+		if currentHash, err := hashMessages(state.messages); err != nil {
+			log.Printf("[TellExec] Error hashing resumed messages for comparison against checkpoint: %v\n", err)
+		} else if currentHash != rc.MessagesHash {
+			log.Printf("[TellExec] Resumed plan %s conversation changed since checkpoint was saved (hash %s != %s) - continuing with the latest conversation state\n", plan.Id, currentHash, rc.MessagesHash)
+		} else {
+			log.Printf("[TellExec] Resumed plan %s conversation unchanged since checkpoint was saved\n", plan.Id)
+		}
+	}
+
+	if stageChanged {
+		hooks.DispatchWebhook(currentOrgId, hooks.WebhookPayload{
+			Event:              hooks.WebhookEventStageChanged,
+			PlanId:             plan.Id,
+			Branch:             branch,
+			Iteration:          iteration,
+			Stage:              currStage,
+			FinishedSubtasks:   finishedSubtasks,
+			UnfinishedSubtasks: unfinishedSubtasks,
+			TotalRequestTokens: requestTokens,
+		})
+	}
+
 	stop := []string{"<PlandexFinish/>"}
 	var modelConfig shared.ModelRoleConfig
 	if isPlanningStage {
@@ -409,6 +516,11 @@ func execTellPlan(params execTellPlanParams) {
 		go state.queuePendingBuilds()
 	}
 
+	go startCheckpointing(state, active)
+	go startProgressReporting(state, active, modelConfig.GetReservedOutputTokens(), func() int {
+		return shared.GetNumTokensEstimate(active.CurrentReplyContent)
+	})
+
 	UpdateActivePlan(planId, branch, func(ap *types.ActivePlan) {
 		ap.CurrentStreamingReplyId = state.replyId
 		ap.CurrentReplyDoneCh = make(chan bool, 1)
@@ -0,0 +1,13 @@
+package server
+
+import "plandex-server/model/plan"
+
+// Init runs once when the server process starts, after the database
+// connection is established and before the server begins accepting
+// requests. It's the single place orphaned-plan recovery is invoked from,
+// independent of whether this instance also calls plan.SetActivePlanStore
+// - a single-node deployment still needs plans left in PlanStatusReplying
+// by a prior crash paused on restart.
+func Init() {
+	plan.RecoverOrphanedPlans()
+}
@@ -0,0 +1,7 @@
+package shared
+
+// PlanStatusPaused marks a plan that was checkpointed mid-stream (after a
+// server crash or client disconnect) and is waiting for a user to resume
+// it via plan.ResumeActivePlan, as opposed to having been stopped by the
+// user or having finished normally.
+const PlanStatusPaused = "paused"
@@ -0,0 +1,7 @@
+package shared
+
+// StreamMessageKeepalive is sent down an otherwise-idle plan stream to
+// keep long-lived connections (e.g. the gRPC Subscribe stream) alive
+// through intermediate load balancers. Clients ack it with a
+// KeepaliveAck SubscribeMessage.
+const StreamMessageKeepalive = "keepalive"
@@ -0,0 +1,29 @@
+package shared
+
+import "time"
+
+// StreamMessageProgress reports incremental progress on an in-progress
+// reply stream, so CLI clients can render a real progress bar with a
+// token-rate ETA instead of an opaque spinner.
+const StreamMessageProgress = "progress"
+
+// StreamMessageProgressStage identifies which part of the tell pipeline a
+// StreamMessageProgressInfo update was captured during.
+type StreamMessageProgressStage string
+
+const (
+	StreamMessageProgressStagePlanning       StreamMessageProgressStage = "planning"
+	StreamMessageProgressStageContext        StreamMessageProgressStage = "context"
+	StreamMessageProgressStageImplementation StreamMessageProgressStage = "implementation"
+)
+
+// StreamMessageProgressInfo is the payload of a StreamMessageProgress
+// stream message.
+type StreamMessageProgressInfo struct {
+	Stage             StreamMessageProgressStage `json:"stage"`
+	SubtaskIndex      int                        `json:"subtaskIndex"`
+	SubtaskTotal      int                        `json:"subtaskTotal"`
+	TokensProduced    int                        `json:"tokensProduced"`
+	TokensPerSecond   float64                    `json:"tokensPerSecond"`
+	EstimatedTimeLeft time.Duration              `json:"estimatedTimeLeft"`
+}
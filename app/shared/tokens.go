@@ -0,0 +1,13 @@
+package shared
+
+// avgCharsPerToken approximates GPT tokenization without pulling in a
+// tokenizer, trading precision for speed on the hot path that redraws
+// progress on every tick.
+const avgCharsPerToken = 4
+
+// GetNumTokensEstimate approximates the GPT token count of s, for callers
+// that only have a raw string - like an in-progress reply's content so far
+// - rather than a full chat message to pass to GetMessagesTokenEstimate.
+func GetNumTokensEstimate(s string) int {
+	return len(s) / avgCharsPerToken
+}